@@ -0,0 +1,125 @@
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestAddRoutesConcurrentNoDeadlock is a regression test for a lock-ordering
+// deadlock: AddRoutes used to always take the receiver's mutex before the
+// argument's, so a.AddRoutes(b) racing with b.AddRoutes(a) on two
+// goroutines could deadlock both servers forever.
+func TestAddRoutesConcurrentNoDeadlock(t *testing.T) {
+	a := NewServer(":0")
+	b := NewServer(":0")
+	a.HandleFunc("/a", func(w http.ResponseWriter, r *http.Request) {})
+	b.HandleFunc("/b", func(w http.ResponseWriter, r *http.Request) {})
+
+	done := make(chan struct{})
+	go func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 200; i++ {
+			wg.Add(2)
+			go func() { defer wg.Done(); a.AddRoutes(b) }()
+			go func() { defer wg.Done(); b.AddRoutes(a) }()
+		}
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AddRoutes deadlocked")
+	}
+}
+
+// TestUseConcurrentWithServeHTTP exercises Use() being called while requests
+// are in flight; it exists to be run under `go test -race` to catch a data
+// race on Server.middlewares.
+func TestUseConcurrentWithServeHTTP(t *testing.T) {
+	s := NewServer(":0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	stop := make(chan struct{})
+	var serveWG sync.WaitGroup
+	serveWG.Add(1)
+	go func() {
+		defer serveWG.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				s.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		s.Use(func(next http.Handler) http.Handler { return next })
+	}
+
+	close(stop)
+	serveWG.Wait()
+}
+
+// TestAddPrehandlerConcurrentWithServeHTTP exercises AddPrehandler being
+// called while requests are in flight; it exists to be run under
+// `go test -race` to catch a data race on Server.prehandlers.
+func TestAddPrehandlerConcurrentWithServeHTTP(t *testing.T) {
+	s := NewServer(":0")
+	s.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+
+	stop := make(chan struct{})
+	var serveWG sync.WaitGroup
+	serveWG.Add(1)
+	go func() {
+		defer serveWG.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				s.ServeHTTP(httptest.NewRecorder(), req)
+			}
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		s.AddPrehandler(func(w http.ResponseWriter, r *http.Request) bool { return false })
+	}
+
+	close(stop)
+	serveWG.Wait()
+}
+
+func TestJanitorExpiresTTLRoute(t *testing.T) {
+	s := NewServer(":0")
+	s.StartJanitor(10 * time.Millisecond)
+	defer s.StopJanitor()
+
+	s.ServeBytesWithTTL("/tmp.txt", []byte("hi"), 10*time.Millisecond)
+
+	req := httptest.NewRequest(http.MethodGet, "/tmp.txt", nil)
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if rr.Body.String() != "hi" {
+		t.Fatalf("expected route to be live before ttl, got %q", rr.Body.String())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	req = httptest.NewRequest(http.MethodGet, "/tmp.txt", nil)
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), "404") {
+		t.Fatalf("expected route to be expired, got %q", rr.Body.String())
+	}
+}
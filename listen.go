@@ -0,0 +1,30 @@
+package mux
+
+import (
+	"net"
+	"net/http/fcgi"
+)
+
+// ListenAndServeFCGI listens on network/addr (e.g. "tcp", "127.0.0.1:9000")
+// and serves the Server over FastCGI, for deployment behind a front-end
+// like nginx or lighttpd instead of terminating HTTP directly.
+func (s *Server) ListenAndServeFCGI(network, addr string) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return fcgi.Serve(l, s)
+}
+
+// ListenAndServeUnix listens on a Unix domain socket at path and serves the
+// Server over plain HTTP, for deployment behind a reverse proxy that talks
+// to a local socket rather than a TCP port.
+func (s *Server) ListenAndServeUnix(path string) error {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	return s.HTTPServer.Serve(l)
+}
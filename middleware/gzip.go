@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// gzipWriter wraps http.ResponseWriter so Write goes through a gzip.Writer
+// while headers are still set on the underlying ResponseWriter. Content-
+// Length is stripped just before the header is flushed, since a handler
+// setting it reflects the uncompressed body size, not the compressed
+// bytes actually written to the wire.
+type gzipWriter struct {
+	http.ResponseWriter
+	gw          io.Writer
+	wroteHeader bool
+}
+
+func (w *gzipWriter) WriteHeader(status int) {
+	w.wroteHeader = true
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *gzipWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.gw.Write(b)
+}
+
+// Hijack lets gzipWriter pass through to the underlying ResponseWriter's
+// http.Hijacker, so wrapping a handler in Gzip doesn't break WebSocket
+// upgrades or other hijacking handlers.
+func (w *gzipWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hj.Hijack()
+}
+
+// Gzip returns middleware that compresses the response body when the
+// client's Accept-Encoding header allows gzip.
+func Gzip() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			gw := gzip.NewWriter(w)
+			defer gw.Close()
+
+			next.ServeHTTP(&gzipWriter{ResponseWriter: w, gw: gw}, r)
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// hijackableRecorder wraps httptest.ResponseRecorder with a no-op Hijacker,
+// since ResponseRecorder itself doesn't implement http.Hijacker.
+type hijackableRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackableRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+// TestGzipStripsStaleContentLength is a regression test: a handler that sets
+// Content-Length for its uncompressed body must not leak that header
+// through once Gzip has rewritten the body to a smaller, compressed one.
+func TestGzipStripsStaleContentLength(t *testing.T) {
+	body := []byte("a response body that is long enough to actually compress down")
+	h := Gzip()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "9999")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if cl := rr.Header().Get("Content-Length"); cl != "" {
+		t.Fatalf("expected Content-Length to be stripped, got %q", cl)
+	}
+
+	gr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	defer gr.Close()
+	got, err := io.ReadAll(gr)
+	if err != nil {
+		t.Fatalf("reading gzipped body: %v", err)
+	}
+	if string(got) != string(body) {
+		t.Fatalf("expected body %q, got %q", body, got)
+	}
+}
+
+func TestGzipSkipsUncompressibleClients(t *testing.T) {
+	h := Gzip()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("plain"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if enc := rr.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected no Content-Encoding without Accept-Encoding: gzip, got %q", enc)
+	}
+	if rr.Body.String() != "plain" {
+		t.Fatalf("expected untouched plain body, got %q", rr.Body.String())
+	}
+}
+
+// TestGzipWriterHijackPassesThrough is a regression test: wrapping a
+// hijacking handler (e.g. a WebSocket upgrade) in Gzip must not break the
+// underlying http.Hijacker.
+func TestGzipWriterHijackPassesThrough(t *testing.T) {
+	base := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	hijacked := false
+	h := Gzip()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected gzipWriter to implement http.Hijacker")
+		}
+		if _, _, err := hj.Hijack(); err != nil {
+			t.Fatalf("Hijack: %v", err)
+		}
+		hijacked = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	h.ServeHTTP(base, req)
+
+	if !hijacked || !base.hijacked {
+		t.Fatal("expected Hijack to reach the underlying ResponseWriter")
+	}
+}
+
+func TestGzipWriterHijackUnsupported(t *testing.T) {
+	w := &gzipWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := w.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("expected http.ErrNotSupported, got %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"bufio"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statusWriter wraps http.ResponseWriter to capture the status code written
+// by the wrapped handler, defaulting to 200 if WriteHeader is never called.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Hijack lets statusWriter pass through to the underlying ResponseWriter's
+// http.Hijacker, so wrapping a handler in Logging doesn't break WebSocket
+// upgrades or other hijacking handlers.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hj.Hijack()
+}
+
+// Logging returns middleware that logs the method, path, response status
+// and duration of every request.
+func Logging() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			start := time.Now()
+			next.ServeHTTP(sw, r)
+			log.Printf("%s %s %d %s", r.Method, r.URL.Path, sw.status, time.Since(start))
+		})
+	}
+}
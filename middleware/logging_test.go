@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestLoggingWriterHijackPassesThrough is a regression test: wrapping a
+// hijacking handler (e.g. a WebSocket upgrade) in Logging must not break
+// the underlying http.Hijacker.
+func TestLoggingWriterHijackPassesThrough(t *testing.T) {
+	base := &hijackableRecorder{ResponseRecorder: httptest.NewRecorder()}
+	hijacked := false
+	h := Logging()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hj, ok := w.(http.Hijacker)
+		if !ok {
+			t.Fatal("expected statusWriter to implement http.Hijacker")
+		}
+		if _, _, err := hj.Hijack(); err != nil {
+			t.Fatalf("Hijack: %v", err)
+		}
+		hijacked = true
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(base, req)
+
+	if !hijacked || !base.hijacked {
+		t.Fatal("expected Hijack to reach the underlying ResponseWriter")
+	}
+}
+
+func TestLoggingWriterHijackUnsupported(t *testing.T) {
+	w := &statusWriter{ResponseWriter: httptest.NewRecorder()}
+	if _, _, err := w.Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Fatalf("expected http.ErrNotSupported, got %v", err)
+	}
+}
+
+func TestLoggingCapturesStatus(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(prev)
+
+	h := Logging()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusTeapot {
+		t.Fatalf("expected status %d, got %d", http.StatusTeapot, rr.Code)
+	}
+	if !strings.Contains(buf.String(), "GET / 418 ") {
+		t.Fatalf("expected the log line to record status %d, got %q", http.StatusTeapot, buf.String())
+	}
+}
@@ -7,16 +7,38 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Server struct {
-	HTTPServer                    *http.Server
+	HTTPServer *http.Server
+
+	// mu guards every unexported field below: routes can be registered concurrently
+	// with requests being served, and ServeFileWithTTL/ServeBytesWithTTL
+	// register and later unregister routes from the janitor goroutine.
+	mu                            sync.RWMutex
 	prehandlers                   []func(http.ResponseWriter, *http.Request) bool
 	r, mr                         map[string]func(http.ResponseWriter, *http.Request)
 	get, post, put, delete, patch map[string]func(http.ResponseWriter, *http.Request)
+	// trees holds the pattern-route trie for each HTTP method, used for
+	// routes registered with a ":name" or "*name" segment.
+	trees map[string]*node
+	// middlewares wraps dispatch, outermost first. Populated via Use.
+	middlewares []func(http.Handler) http.Handler
+	// ShutdownTimeout bounds how long Stop waits for in-flight requests to
+	// drain before giving up. Zero means DefaultShutdownTimeout.
+	ShutdownTimeout time.Duration
+
+	dirListing  bool
+	expirations map[string]time.Time
+	janitorStop chan struct{}
 }
 
+// DefaultShutdownTimeout is the drain deadline Stop uses when
+// Server.ShutdownTimeout is left at its zero value.
+const DefaultShutdownTimeout = time.Second
+
 func NewServer(addr string) *Server {
 	s := &Server{}
 	s.HTTPServer = &http.Server{Addr: addr, Handler: s}
@@ -27,16 +49,32 @@ func NewServer(addr string) *Server {
 	s.put = make(map[string]func(http.ResponseWriter, *http.Request))
 	s.delete = make(map[string]func(http.ResponseWriter, *http.Request))
 	s.patch = make(map[string]func(http.ResponseWriter, *http.Request))
+	s.trees = make(map[string]*node)
 	return s
 }
 
+// addPatternRoute registers f for pattern under method's trie, creating the
+// trie root on first use. Callers must hold s.mu for writing.
+func (s *Server) addPatternRoute(method, pattern string, f func(http.ResponseWriter, *http.Request)) {
+	t, ok := s.trees[method]
+	if !ok {
+		t = newNode()
+		s.trees[method] = t
+	}
+	t.insert(pattern, f)
+}
+
 func (s *Server) ListenAndServe() error {
 	return s.HTTPServer.ListenAndServe()
 }
 
 func (s *Server) Stop() error {
 	if s != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		timeout := s.ShutdownTimeout
+		if timeout <= 0 {
+			timeout = DefaultShutdownTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
 		defer cancel()
 		// Doesn't block if no connections, but will otherwise wait
 		// until the timeout deadline.
@@ -47,30 +85,64 @@ func (s *Server) Stop() error {
 }
 
 func (s *Server) GET(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(url) {
+		s.addPatternRoute(http.MethodGet, url, f)
+		return
+	}
 	s.get[url] = f
 }
 
 func (s *Server) POST(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(url) {
+		s.addPatternRoute(http.MethodPost, url, f)
+		return
+	}
 	s.post[url] = f
 }
 
 func (s *Server) PUT(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(url) {
+		s.addPatternRoute(http.MethodPut, url, f)
+		return
+	}
 	s.put[url] = f
 }
 
 func (s *Server) DELETE(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(url) {
+		s.addPatternRoute(http.MethodDelete, url, f)
+		return
+	}
 	s.delete[url] = f
 }
 
 func (s *Server) PATCH(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isPattern(url) {
+		s.addPatternRoute(http.MethodPatch, url, f)
+		return
+	}
 	s.patch[url] = f
 }
 
 func (s *Server) HandleFunc(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = f
 }
 
 func (s *Server) ServeBytes(url string, bytes []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", mime.TypeByExtension(path.Ext(url)))
 		w.Write(bytes)
@@ -78,12 +150,16 @@ func (s *Server) ServeBytes(url string, bytes []byte) {
 }
 
 func (s *Server) ServeFile(uri string, path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[uri] = func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, path)
 	}
 }
 
 func (s *Server) HandleWoff(url string, bytes []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		SetWoffHeader(w)
 		w.Write(bytes)
@@ -91,6 +167,8 @@ func (s *Server) HandleWoff(url string, bytes []byte) {
 }
 
 func (s *Server) HandleHtml(url string, text []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
 		w.Write(text)
@@ -98,6 +176,8 @@ func (s *Server) HandleHtml(url string, text []byte) {
 }
 
 func (s *Server) HandleHtmlFunc(url string, fn func(w http.ResponseWriter, r *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/html")
 		fn(w, r)
@@ -105,18 +185,24 @@ func (s *Server) HandleHtmlFunc(url string, fn func(w http.ResponseWriter, r *ht
 }
 
 func (s *Server) HandleJs(url string, text []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/javascript")
 		w.Write(text)
 	}
 }
 func (s *Server) HandleCss(url string, text []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/css")
 		w.Write(text)
 	}
 }
 func (s *Server) HandleSvg(url string, text []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "image/svg+xml")
 		w.Write(text)
@@ -124,57 +210,91 @@ func (s *Server) HandleSvg(url string, text []byte) {
 }
 
 func (s *Server) HandleMultiReqs(url string, f func(http.ResponseWriter, *http.Request)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.mr[url] = f
 }
 
 func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.r[pattern] = h.ServeHTTP
 }
 
+// Use registers middleware that wraps the final route dispatch, in the
+// order given: the first mw passed is the outermost. Use runs after the
+// legacy prehandlers (see AddPrehandler) have had a chance to interrupt.
+func (s *Server) Use(mw ...func(http.Handler) http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middlewares = append(s.middlewares, mw...)
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	for _, v := range s.prehandlers {
+	s.mu.RLock()
+	prehandlers := s.prehandlers
+	middlewares := s.middlewares
+	s.mu.RUnlock()
+
+	for _, v := range prehandlers {
 		interrupt := v(w, r)
 		if interrupt {
 			return
 		}
 	}
+
+	var h http.Handler = http.HandlerFunc(s.dispatch)
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	h.ServeHTTP(w, r)
+}
+
+// dispatch resolves r against the registered routes in this order:
+// per-method exact maps, then the per-method pattern trie, then the exact
+// map shared by all methods (s.r), then the prefix map (s.mr). The matching
+// handler is looked up under s.mu and invoked after it is released, so a
+// handler is free to register further routes without deadlocking.
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request) {
 	url := strings.Split(r.URL.String(), "?")[0]
 
+	s.mu.RLock()
+	var h func(http.ResponseWriter, *http.Request)
+	var params map[string]string
+
 	switch r.Method {
 	case http.MethodGet:
-		if h, ok := s.get[url]; ok {
-			h(w, r)
-			return
-		}
+		h = s.get[url]
 	case http.MethodPost:
-		if h, ok := s.post[url]; ok {
-			h(w, r)
-			return
-		}
+		h = s.post[url]
 	case http.MethodPut:
-		if h, ok := s.put[url]; ok {
-			h(w, r)
-			return
-		}
+		h = s.put[url]
 	case http.MethodDelete:
-		if h, ok := s.delete[url]; ok {
-			h(w, r)
-			return
-		}
+		h = s.delete[url]
 	case http.MethodPatch:
-		if h, ok := s.patch[url]; ok {
-			h(w, r)
-			return
+		h = s.patch[url]
+	}
+
+	if h == nil {
+		if t, ok := s.trees[r.Method]; ok {
+			h, params, _ = t.search(url)
+		}
+	}
+	if h == nil {
+		h = s.r[url]
+	}
+	if h == nil {
+		if k, ok := hasPreffixInMap(s.mr, r.URL.String()); ok {
+			h = s.mr[k]
 		}
 	}
+	s.mu.RUnlock()
 
-	if h, ok := s.r[url]; ok {
-		h(w, r)
-	} else if k, ok := hasPreffixInMap(s.mr, r.URL.String()); ok {
-		s.mr[k](w, r)
-	} else {
+	if h == nil {
 		fmt.Fprint(w, `<!DOCTYPE html><html><head><title>404</title><meta charset="utf-8"><meta name="viewpos" content="width=device-width"></head><body>404 not found</body></html>`)
+		return
 	}
+	h(w, withParams(r, params))
 }
 
 func (s *Server) findMethod(url string) (string, func(http.ResponseWriter, *http.Request), bool) {
@@ -193,11 +313,30 @@ func hasPreffixInMap(m map[string]func(http.ResponseWriter, *http.Request), p st
 
 // AddPrehandler adds prehandler which returns interrupt
 func (s *Server) AddPrehandler(f func(http.ResponseWriter, *http.Request) bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.prehandlers = append(s.prehandlers, f)
 }
 
-// AddRoutes adds all s2's routes to server
+// AddRoutes adds s2's method-agnostic exact routes, prefix routes and
+// pattern routes to server, keeping server's own route wherever both define
+// one; s2's per-method exact routes (GET, POST, PUT, DELETE, PATCH on a
+// plain path) are not copied. The two servers' mutexes are
+// acquired in a fixed order based on pointer address (rather than always
+// s then s2) so that s.AddRoutes(s2) and s2.AddRoutes(s) running
+// concurrently on different goroutines can't deadlock on each other.
 func (s *Server) AddRoutes(s2 *Server) {
+	first, second := s, s2
+	if fmt.Sprintf("%p", s2) < fmt.Sprintf("%p", s) {
+		first, second = s2, s
+	}
+	first.mu.Lock()
+	defer first.mu.Unlock()
+	if second != first {
+		second.mu.Lock()
+		defer second.mu.Unlock()
+	}
+
 	for k, v := range s2.r {
 		_, ok := s.r[k]
 		if !ok {
@@ -211,4 +350,30 @@ func (s *Server) AddRoutes(s2 *Server) {
 			s.mr[k] = v
 		}
 	}
+
+	for method, tree := range s2.trees {
+		if _, ok := s.trees[method]; !ok {
+			s.trees[method] = newNode()
+		}
+		mergeTree(s.trees[method], tree, "")
+	}
+}
+
+// mergeTree copies every registered handler from src into dst, re-inserting
+// each by its original pattern so dst's existing routes take precedence.
+func mergeTree(dst, src *node, prefix string) {
+	if src.handler != nil {
+		if _, _, ok := dst.search(prefix); !ok {
+			dst.insert(prefix, src.handler)
+		}
+	}
+	for seg, child := range src.static {
+		mergeTree(dst, child, prefix+"/"+seg)
+	}
+	if src.param != nil {
+		mergeTree(dst, src.param, prefix+"/:"+src.param.paramName)
+	}
+	if src.catchall != nil {
+		mergeTree(dst, src.catchall, prefix+"/*"+src.catchall.catchallName)
+	}
 }
@@ -0,0 +1,23 @@
+package mux
+
+import (
+	"context"
+	"net/http"
+)
+
+type paramsKey struct{}
+
+// Params returns the path parameters captured for r by a pattern route
+// registered with a ":name" or "*name" segment, e.g. "/users/:id". It
+// returns nil if r was matched by an exact route or no route at all.
+func Params(r *http.Request) map[string]string {
+	v, _ := r.Context().Value(paramsKey{}).(map[string]string)
+	return v
+}
+
+func withParams(r *http.Request, params map[string]string) *http.Request {
+	if params == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), paramsKey{}, params))
+}
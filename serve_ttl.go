@@ -0,0 +1,156 @@
+package mux
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultJanitorInterval is the sweep cadence StartJanitor uses when called
+// with interval <= 0, and the cadence ServeBytesWithTTL/ServeFileWithTTL
+// lazily start the janitor with.
+const DefaultJanitorInterval = time.Minute
+
+// DirListing controls whether ServeDir shows a directory index for
+// directories that have no index.html. It defaults to false, matching the
+// safer behavior of most static file servers deployed behind a proxy.
+func (s *Server) DirListing() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.dirListing
+}
+
+// SetDirListing toggles the directory-listing behavior used by ServeDir.
+func (s *Server) SetDirListing(enabled bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dirListing = enabled
+}
+
+// ServeDir registers urlPrefix under the prefix-match map to serve the
+// directory tree rooted at fsPath. Paths are resolved with symlinks
+// evaluated so a symlink inside fsPath can't be used to escape it, and
+// directories without an index.html return 404 unless DirListing is
+// enabled.
+func (s *Server) ServeDir(urlPrefix, fsPath string) {
+	root, err := filepath.Abs(fsPath)
+	if err == nil {
+		if resolved, err := filepath.EvalSymlinks(root); err == nil {
+			root = resolved
+		}
+	}
+	fileServer := http.FileServer(http.Dir(root))
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		rel := strings.TrimPrefix(r.URL.Path, urlPrefix)
+		full := filepath.Join(root, rel)
+
+		resolved, err := filepath.EvalSymlinks(full)
+		if err != nil {
+			resolved = full
+		}
+		if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+			http.NotFound(w, r)
+			return
+		}
+
+		if !s.DirListing() {
+			if info, err := os.Stat(resolved); err == nil && info.IsDir() {
+				if _, err := os.Stat(filepath.Join(resolved, "index.html")); err != nil {
+					http.NotFound(w, r)
+					return
+				}
+			}
+		}
+
+		http.StripPrefix(urlPrefix, fileServer).ServeHTTP(w, r)
+	}
+
+	s.mu.Lock()
+	s.mr[urlPrefix] = handler
+	s.mu.Unlock()
+}
+
+// ServeBytesWithTTL behaves like ServeBytes, but the route is automatically
+// unregistered by the janitor goroutine once ttl has elapsed.
+func (s *Server) ServeBytesWithTTL(url string, bytes []byte, ttl time.Duration) {
+	s.ServeBytes(url, bytes)
+	s.expireRoute(url, ttl)
+}
+
+// ServeFileWithTTL behaves like ServeFile, but the route is automatically
+// unregistered by the janitor goroutine once ttl has elapsed.
+func (s *Server) ServeFileWithTTL(uri, path string, ttl time.Duration) {
+	s.ServeFile(uri, path)
+	s.expireRoute(uri, ttl)
+}
+
+// expireRoute schedules url for removal from s.r once ttl elapses, starting
+// the janitor with its default interval if it isn't already running.
+func (s *Server) expireRoute(url string, ttl time.Duration) {
+	s.mu.Lock()
+	if s.expirations == nil {
+		s.expirations = make(map[string]time.Time)
+	}
+	s.expirations[url] = time.Now().Add(ttl)
+	s.mu.Unlock()
+
+	s.StartJanitor(0)
+}
+
+// StartJanitor starts the background goroutine that removes expired TTL
+// routes, sweeping every interval (DefaultJanitorInterval if interval <=
+// 0). It is a no-op if the janitor is already running. Safe to call
+// concurrently with route registration.
+func (s *Server) StartJanitor(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+
+	s.mu.Lock()
+	if s.janitorStop != nil {
+		s.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	s.janitorStop = stop
+	s.mu.Unlock()
+
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-t.C:
+				s.sweepExpired(now)
+			}
+		}
+	}()
+}
+
+// StopJanitor stops the background sweep started by StartJanitor. It is a
+// no-op if the janitor isn't running.
+func (s *Server) StopJanitor() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.janitorStop == nil {
+		return
+	}
+	close(s.janitorStop)
+	s.janitorStop = nil
+}
+
+func (s *Server) sweepExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for url, expiry := range s.expirations {
+		if now.After(expiry) {
+			delete(s.r, url)
+			delete(s.expirations, url)
+		}
+	}
+}
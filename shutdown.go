@@ -0,0 +1,47 @@
+package mux
+
+import (
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// RegisterOnShutdown registers f to be started in its own goroutine when
+// Stop calls Shutdown, once the listeners are closed; Shutdown does not wait
+// for f to finish. It is a thin wrapper over http.Server.RegisterOnShutdown,
+// letting callers flush caches or close DB pools while requests drain.
+func (s *Server) RegisterOnShutdown(f func()) {
+	s.HTTPServer.RegisterOnShutdown(f)
+}
+
+// RunUntilSignal starts the server in the background, blocks until one of
+// sigs is received (SIGINT and SIGTERM if none are given), then drains
+// in-flight requests via Stop. It returns any error from ListenAndServe
+// other than http.ErrServerClosed, or the error from Stop if that fails
+// instead.
+func (s *Server) RunUntilSignal(sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	errc := make(chan error, 1)
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errc <- err
+			return
+		}
+		errc <- nil
+	}()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, sigs...)
+	defer signal.Stop(ch)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ch:
+		return s.Stop()
+	}
+}
@@ -0,0 +1,115 @@
+package mux
+
+import (
+	"net/http"
+	"strings"
+)
+
+// node is a segment of a per-method routing trie. Static children are
+// matched first, then a single ":name" child, then a "*name" catch-all
+// that swallows the remainder of the path.
+type node struct {
+	static       map[string]*node
+	param        *node
+	paramName    string
+	catchall     *node
+	catchallName string
+	handler      func(http.ResponseWriter, *http.Request)
+}
+
+func newNode() *node {
+	return &node{static: make(map[string]*node)}
+}
+
+// insert adds pattern (e.g. "/users/:id/posts/*rest") to the trie rooted at n.
+func (n *node) insert(pattern string, h func(http.ResponseWriter, *http.Request)) {
+	segs := splitPath(pattern)
+	cur := n
+	for _, seg := range segs {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			if cur.param == nil {
+				cur.param = newNode()
+			}
+			cur.param.paramName = seg[1:]
+			cur = cur.param
+		case strings.HasPrefix(seg, "*"):
+			if cur.catchall == nil {
+				cur.catchall = newNode()
+			}
+			cur.catchall.catchallName = seg[1:]
+			cur = cur.catchall
+		default:
+			child, ok := cur.static[seg]
+			if !ok {
+				child = newNode()
+				cur.static[seg] = child
+			}
+			cur = child
+		}
+	}
+	cur.handler = h
+}
+
+// search walks the trie for path, returning the matched handler and any
+// captured path parameters.
+func (n *node) search(path string) (func(http.ResponseWriter, *http.Request), map[string]string, bool) {
+	return n.searchSegs(splitPath(path))
+}
+
+// searchSegs matches segs against n, preferring a static child but
+// backtracking to try n's param and catch-all siblings if that static
+// subtree turns out to be a dead end — otherwise a route like
+// "/a/:x/d" could never match "/a/b/d" once some other route had also
+// registered a static "/a/b/..." branch.
+func (n *node) searchSegs(segs []string) (func(http.ResponseWriter, *http.Request), map[string]string, bool) {
+	if len(segs) == 0 {
+		if n.handler != nil {
+			return n.handler, nil, true
+		}
+		if n.catchall != nil {
+			// A catch-all also matches its own mount point with an empty
+			// capture, e.g. "/users/:id/posts/*rest" matches "/users/5/posts".
+			return n.catchall.handler, map[string]string{n.catchall.catchallName: ""}, n.catchall.handler != nil
+		}
+		return nil, nil, false
+	}
+
+	seg, rest := segs[0], segs[1:]
+
+	if child, ok := n.static[seg]; ok {
+		if h, params, ok := child.searchSegs(rest); ok {
+			return h, params, true
+		}
+	}
+
+	if n.param != nil {
+		if h, params, ok := n.param.searchSegs(rest); ok {
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[n.param.paramName] = seg
+			return h, params, true
+		}
+	}
+
+	if n.catchall != nil {
+		params := map[string]string{n.catchall.catchallName: strings.Join(segs, "/")}
+		return n.catchall.handler, params, n.catchall.handler != nil
+	}
+
+	return nil, nil, false
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+// isPattern reports whether url contains a ":name" or "*name" segment.
+func isPattern(url string) bool {
+	return strings.Contains(url, "/:") || strings.Contains(url, "/*")
+}
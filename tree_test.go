@@ -0,0 +1,82 @@
+package mux
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNodeSearchStaticAndParam(t *testing.T) {
+	n := newNode()
+	n.insert("/users/:id", func(w http.ResponseWriter, r *http.Request) {})
+	n.insert("/users/me", func(w http.ResponseWriter, r *http.Request) {})
+
+	if _, params, ok := n.search("/users/me"); !ok || params != nil {
+		t.Fatal("expected static route to win over param route")
+	}
+
+	_, params, ok := n.search("/users/42")
+	if !ok {
+		t.Fatal("expected param route to match")
+	}
+	if params["id"] != "42" {
+		t.Fatalf("expected id=42, got %+v", params)
+	}
+
+	if _, _, ok := n.search("/users"); ok {
+		t.Fatal("expected no match for a path with too few segments")
+	}
+}
+
+func TestNodeSearchCatchall(t *testing.T) {
+	n := newNode()
+	n.insert("/users/:id/posts/*rest", func(w http.ResponseWriter, r *http.Request) {})
+
+	for _, p := range []string{"/users/5/posts", "/users/5/posts/"} {
+		_, params, ok := n.search(p)
+		if !ok {
+			t.Fatalf("expected zero-width catch-all to match %q", p)
+		}
+		if params["rest"] != "" {
+			t.Fatalf("expected empty rest capture for %q, got %q", p, params["rest"])
+		}
+	}
+
+	_, params, ok := n.search("/users/5/posts/42/comments")
+	if !ok {
+		t.Fatal("expected catch-all to swallow the remaining path")
+	}
+	if params["rest"] != "42/comments" {
+		t.Fatalf("unexpected rest capture: %q", params["rest"])
+	}
+}
+
+// TestNodeSearchBacktracksFromDeadEndStatic is a regression test: a static
+// subtree that doesn't match the rest of the path must not fail the whole
+// lookup if a sibling param/catch-all branch would have matched instead.
+func TestNodeSearchBacktracksFromDeadEndStatic(t *testing.T) {
+	n := newNode()
+	n.insert("/a/b/c/:q", func(w http.ResponseWriter, r *http.Request) {})
+	n.insert("/a/:x/d", func(w http.ResponseWriter, r *http.Request) {})
+
+	_, params, ok := n.search("/a/b/d")
+	if !ok {
+		t.Fatal("expected backtracking from the dead-end /a/b/... static branch to /a/:x/d")
+	}
+	if params["x"] != "b" {
+		t.Fatalf("expected x=b, got %+v", params)
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	cases := map[string]bool{
+		"/users":         false,
+		"/users/:id":     true,
+		"/static/*path":  true,
+		"/users/me/edit": false,
+	}
+	for url, want := range cases {
+		if got := isPattern(url); got != want {
+			t.Errorf("isPattern(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package mux
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const wsMagicGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocket opcodes, as defined by RFC 6455 section 5.2.
+const (
+	wsOpContinuation = 0x0
+	wsOpText         = 0x1
+	wsOpBinary       = 0x2
+	wsOpClose        = 0x8
+	wsOpPing         = 0x9
+	wsOpPong         = 0xA
+)
+
+// Message type constants for WSConn.ReadMessage/WriteMessage, matching the
+// gorilla/websocket values so handlers written against that API port over
+// with a mechanical import-path swap.
+const (
+	TextMessage   = wsOpText
+	BinaryMessage = wsOpBinary
+)
+
+// DefaultPingInterval is the keepalive cadence used when WSConn.PingInterval
+// is left at its zero value.
+const DefaultPingInterval = 30 * time.Second
+
+// DefaultMaxFrameSize is the frame payload limit used when
+// WSConn.MaxFrameSize is left at its zero value, matching common reverse
+// proxy defaults (e.g. nginx's client_max_body_size-adjacent settings).
+const DefaultMaxFrameSize = 32 << 20 // 32 MiB
+
+// wsStatusMessageTooBig is the RFC 6455 close status for a frame that
+// exceeds the receiver's size limit.
+const wsStatusMessageTooBig = 1009
+
+// WSConn is a hijacked HTTP connection upgraded to the WebSocket protocol.
+type WSConn struct {
+	conn net.Conn
+	br   *bufio.Reader
+
+	// PingInterval controls how often a keepalive ping is sent. It must be
+	// set before Pipe or a manual read loop starts; zero uses DefaultPingInterval.
+	PingInterval time.Duration
+	// MaxFrameSize bounds the payload length accepted from a single frame;
+	// a larger declared length closes the connection with a 1009 (Message
+	// Too Big) status instead of being allocated. Zero uses DefaultMaxFrameSize.
+	MaxFrameSize int64
+
+	writeMu  sync.Mutex
+	closed   chan struct{}
+	closeErr error
+	once     sync.Once
+	// keepaliveOnce starts the ping loop on the first ReadMessage call, so
+	// that a PingInterval set inside the handler takes effect.
+	keepaliveOnce sync.Once
+}
+
+// HandleWebSocket registers a WebSocket endpoint at url. fn is called with a
+// connected WSConn once the RFC 6455 handshake succeeds; fn owns the
+// connection for its lifetime and the connection is closed when fn returns.
+func (s *Server) HandleWebSocket(url string, fn func(*WSConn) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.r[url] = func(w http.ResponseWriter, r *http.Request) {
+		conn, br, err := upgradeWebSocket(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ws := &WSConn{conn: conn, br: br, closed: make(chan struct{})}
+		defer ws.Close()
+		fn(ws)
+	}
+}
+
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.Reader, error) {
+	if r.Header.Get("Upgrade") != "websocket" || r.Header.Get("Connection") == "" {
+		return nil, nil, errors.New("mux: not a websocket upgrade request")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		return nil, nil, errors.New("mux: missing Sec-WebSocket-Key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("mux: response writer does not support hijacking")
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	accept := wsAcceptKey(key)
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + accept + "\r\n\r\n"
+	if _, err := rw.WriteString(resp); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, rw.Reader, nil
+}
+
+func wsAcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+wsMagicGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func (c *WSConn) startKeepalive() {
+	interval := c.PingInterval
+	if interval <= 0 {
+		interval = DefaultPingInterval
+	}
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-c.closed:
+				return
+			case <-t.C:
+				if c.writeFrame(wsOpPing, nil) != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
+// ReadMessage reads the next data frame, transparently answering pings and
+// swallowing pongs. It returns io.EOF once a close frame has been received
+// and echoed.
+func (c *WSConn) ReadMessage() (messageType int, data []byte, err error) {
+	c.keepaliveOnce.Do(c.startKeepalive)
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case wsOpPing:
+			if err := c.writeFrame(wsOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+		case wsOpPong:
+			// keepalive acknowledged; nothing to do
+		case wsOpClose:
+			c.writeFrame(wsOpClose, payload)
+			return 0, nil, io.EOF
+		default:
+			return int(op), payload, nil
+		}
+	}
+}
+
+// WriteMessage sends a single data frame of the given type (TextMessage or
+// BinaryMessage).
+func (c *WSConn) WriteMessage(messageType int, data []byte) error {
+	return c.writeFrame(byte(messageType), data)
+}
+
+// Close sends a close frame and releases the underlying connection. It is
+// safe to call more than once.
+func (c *WSConn) Close() error {
+	c.once.Do(func() {
+		c.writeFrame(wsOpClose, nil)
+		close(c.closed)
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+// Pipe bidirectionally shuttles data between c and dst: bytes read from dst
+// are sent as binary frames on c, and frames read from c are written to dst. It
+// blocks until either side errs or closes, mirroring common reverse-proxy
+// pipe helpers.
+func (c *WSConn) Pipe(dst io.ReadWriter) error {
+	errc := make(chan error, 2)
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := dst.Read(buf)
+			if n > 0 {
+				if werr := c.WriteMessage(BinaryMessage, buf[:n]); werr != nil {
+					errc <- werr
+					return
+				}
+			}
+			if err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			_, data, err := c.ReadMessage()
+			if err != nil {
+				errc <- err
+				return
+			}
+			if _, werr := dst.Write(data); werr != nil {
+				errc <- werr
+				return
+			}
+		}
+	}()
+
+	err := <-errc
+	c.Close()
+	return err
+}
+
+func (c *WSConn) readFrame() (op byte, payload []byte, err error) {
+	hdr := make([]byte, 2)
+	if _, err = io.ReadFull(c.br, hdr); err != nil {
+		return 0, nil, err
+	}
+	op = hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	length := uint64(hdr[1] & 0x7F)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(c.br, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(c.br, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	maxSize := c.MaxFrameSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxFrameSize
+	}
+	if length > uint64(maxSize) {
+		c.writeFrame(wsOpClose, wsCloseStatusPayload(wsStatusMessageTooBig, "message too large"))
+		return 0, nil, fmt.Errorf("mux: frame length %d exceeds max %d bytes", length, maxSize)
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, maskKey[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= maskKey[i%4]
+		}
+	}
+	return op, payload, nil
+}
+
+// wsCloseStatusPayload builds the payload for a close frame carrying a
+// status code and human-readable reason, per RFC 6455 section 5.5.1.
+func wsCloseStatusPayload(code int, reason string) []byte {
+	b := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(b, uint16(code))
+	copy(b[2:], reason)
+	return b
+}
+
+// writeFrame writes a single, final (FIN-set) unmasked frame, as servers
+// are required to send per RFC 6455 section 5.1.
+func (c *WSConn) writeFrame(op byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	var hdr []byte
+	length := len(payload)
+	switch {
+	case length <= 125:
+		hdr = []byte{0x80 | op, byte(length)}
+	case length <= 0xFFFF:
+		hdr = make([]byte, 4)
+		hdr[0] = 0x80 | op
+		hdr[1] = 126
+		binary.BigEndian.PutUint16(hdr[2:], uint16(length))
+	default:
+		hdr = make([]byte, 10)
+		hdr[0] = 0x80 | op
+		hdr[1] = 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(length))
+	}
+
+	if _, err := c.conn.Write(hdr); err != nil {
+		return err
+	}
+	if length > 0 {
+		if _, err := c.conn.Write(payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
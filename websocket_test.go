@@ -0,0 +1,170 @@
+package mux
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func newBufReader(r io.Reader) *bufio.Reader {
+	return bufio.NewReader(r)
+}
+
+// readRawFrame decodes a single unmasked frame as a server must send it,
+// without going through WSConn, so tests can verify writeFrame's wire
+// format independently of readFrame.
+func readRawFrame(t *testing.T, r io.Reader) (op byte, payload []byte, err error) {
+	t.Helper()
+	hdr := make([]byte, 2)
+	if _, err = io.ReadFull(r, hdr); err != nil {
+		return 0, nil, err
+	}
+	op = hdr[0] & 0x0F
+	length := uint64(hdr[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err = io.ReadFull(r, ext); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err = io.ReadFull(r, ext); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	payload = make([]byte, length)
+	_, err = io.ReadFull(r, payload)
+	return op, payload, err
+}
+
+// writeMaskedFrame writes a single masked frame the way a real client
+// must, per RFC 6455 section 5.3.
+func writeMaskedFrame(w io.Writer, op byte, payload []byte) error {
+	var maskKey [4]byte
+	rand.Read(maskKey[:])
+
+	masked := make([]byte, len(payload))
+	for i, b := range payload {
+		masked[i] = b ^ maskKey[i%4]
+	}
+
+	length := len(payload)
+	var hdr []byte
+	switch {
+	case length <= 125:
+		hdr = []byte{0x80 | op, 0x80 | byte(length)}
+	case length <= 0xFFFF:
+		hdr = make([]byte, 4)
+		hdr[0] = 0x80 | op
+		hdr[1] = 0x80 | 126
+		binary.BigEndian.PutUint16(hdr[2:], uint16(length))
+	default:
+		hdr = make([]byte, 10)
+		hdr[0] = 0x80 | op
+		hdr[1] = 0x80 | 127
+		binary.BigEndian.PutUint64(hdr[2:], uint64(length))
+	}
+
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	if _, err := w.Write(maskKey[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(masked)
+	return err
+}
+
+func newTestWSConn(t *testing.T) (*WSConn, net.Conn) {
+	t.Helper()
+	server, client := net.Pipe()
+	t.Cleanup(func() { server.Close(); client.Close() })
+	return &WSConn{conn: server, br: newBufReader(server), closed: make(chan struct{})}, client
+}
+
+func TestWriteFrameWireFormat(t *testing.T) {
+	ws, client := newTestWSConn(t)
+
+	want := []byte("hello over the wire")
+	errc := make(chan error, 1)
+	go func() { errc <- ws.WriteMessage(TextMessage, want) }()
+
+	op, payload, err := readRawFrame(t, client)
+	if err != nil {
+		t.Fatalf("read raw frame: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	if op != wsOpText {
+		t.Fatalf("expected opcode %d, got %d", wsOpText, op)
+	}
+	if string(payload) != string(want) {
+		t.Fatalf("expected payload %q, got %q", want, payload)
+	}
+}
+
+func TestReadFrameRoundTripFromMaskedClientFrame(t *testing.T) {
+	ws, client := newTestWSConn(t)
+
+	want := []byte("a masked client message")
+	errc := make(chan error, 1)
+	go func() { errc <- writeMaskedFrame(client, wsOpBinary, want) }()
+
+	op, payload, err := ws.readFrame()
+	if err != nil {
+		t.Fatalf("readFrame: %v", err)
+	}
+	if err := <-errc; err != nil {
+		t.Fatalf("write masked frame: %v", err)
+	}
+	if op != wsOpBinary {
+		t.Fatalf("expected opcode %d, got %d", wsOpBinary, op)
+	}
+	if string(payload) != string(want) {
+		t.Fatalf("expected payload %q, got %q", want, payload)
+	}
+}
+
+// TestReadFrameRejectsOversizedLength is a regression test: a frame
+// declaring a length beyond MaxFrameSize must be rejected before the
+// payload buffer is allocated, instead of panicking or allocating an
+// unbounded amount of memory per connection.
+func TestReadFrameRejectsOversizedLength(t *testing.T) {
+	ws, client := newTestWSConn(t)
+	ws.MaxFrameSize = 16
+
+	go func() {
+		hdr := []byte{0x80 | wsOpBinary, 127}
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, ^uint64(0))
+		client.Write(hdr)
+		client.Write(ext)
+	}()
+
+	// readFrame responds to the oversized frame with a close frame of its
+	// own before returning the error; drain it so that write doesn't block
+	// forever on an unread net.Pipe.
+	go io.Copy(io.Discard, client)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, _, err := ws.readFrame(); err == nil {
+			t.Error("expected an error for an oversized frame length")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("readFrame did not return for an oversized length")
+	}
+}